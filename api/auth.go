@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/holmes89/go-common/logging"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrUnauthenticated is returned by an Authenticator when it cannot resolve
+// a caller identity from the request.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the caller identity resolved by an Authenticator.
+type Principal struct {
+	UID    string
+	Claims map[string]interface{}
+}
+
+// Authenticator resolves the caller identity from an inbound request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Authorizer decides whether a principal satisfies at least one of a
+// route's required roles.
+type Authorizer interface {
+	Authorize(p Principal, roles ...string) (bool, error)
+}
+
+// withAuth wraps fun with authentication/authorization for routes that
+// declare required roles. authn and authz may be nil; a route with no
+// required roles then runs unauthenticated, but any route that does declare
+// roles is rejected until both are configured.
+func withAuth(authn Authenticator, authz Authorizer, roles []string, fun http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var principal Principal
+		if authn != nil {
+			p, err := authn.Authenticate(r)
+			if err != nil {
+				if len(roles) > 0 {
+					writeError(w, http.StatusUnauthorized, "unauthenticated")
+					return
+				}
+			} else {
+				principal = p
+				ctx = CtxWithUserUID(ctx, principal.UID)
+				ctx = CtxWithClaims(ctx, principal.Claims)
+				logging.AddFields(ctx, func(c zerolog.Context) zerolog.Context {
+					return c.Str("user_uid", principal.UID)
+				})
+			}
+		} else if len(roles) > 0 {
+			writeError(w, http.StatusUnauthorized, "unauthenticated")
+			return
+		}
+
+		if len(roles) > 0 {
+			if authz == nil {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			ok, err := authz.Authorize(principal, roles...)
+			if err != nil {
+				logger := logging.FromCtx(ctx)
+				logger.Error().Err(err).Msg("authorization check failed")
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+			if !ok {
+				writeError(w, http.StatusForbidden, "forbidden")
+				return
+			}
+		}
+
+		fun(w, r.WithContext(ctx))
+	}
+}
+
+// DevAuthenticator trusts the DEV_ID env var as the caller's UID, mirroring
+// the DEV_ID shortcut the Lambda handler already uses for local development.
+type DevAuthenticator struct {
+	uid string
+}
+
+// NewDevAuthenticator reads DEV_ID once at construction time.
+func NewDevAuthenticator() *DevAuthenticator {
+	return &DevAuthenticator{uid: os.Getenv("DEV_ID")}
+}
+
+func (a *DevAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if a.uid == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+	return Principal{UID: a.uid}, nil
+}
+
+// APIKeyAuthenticator authenticates requests carrying a known key in
+// header.
+type APIKeyAuthenticator struct {
+	header string
+	keys   map[string]string // key -> uid
+}
+
+// NewAPIKeyAuthenticator builds an Authenticator that maps values of header
+// to a UID via keys. header defaults to "X-Api-Key".
+func NewAPIKeyAuthenticator(header string, keys map[string]string) *APIKeyAuthenticator {
+	if header == "" {
+		header = "X-Api-Key"
+	}
+	return &APIKeyAuthenticator{header: header, keys: keys}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	key := r.Header.Get(a.header)
+	if key == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+	uid, ok := a.keys[key]
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+	return Principal{UID: uid}, nil
+}
+
+// JWTConfig configures a JWTAuthenticator.
+type JWTConfig struct {
+	JWKSURL         string
+	Issuer          string
+	Audience        string
+	RefreshInterval time.Duration
+}
+
+// JWTAuthenticator validates bearer tokens against a JWKS endpoint, caching
+// the fetched keys between refreshes.
+type JWTAuthenticator struct {
+	conf JWTConfig
+	jwks *keyfunc.JWKS
+}
+
+// NewJWTAuthenticator fetches the JWKS at conf.JWKSURL and starts its
+// background refresh.
+func NewJWTAuthenticator(conf JWTConfig) (*JWTAuthenticator, error) {
+	if conf.RefreshInterval == 0 {
+		conf.RefreshInterval = time.Hour
+	}
+	jwks, err := keyfunc.Get(conf.JWKSURL, keyfunc.Options{
+		RefreshInterval: conf.RefreshInterval,
+		RefreshErrorHandler: func(err error) {
+			log.Error().Err(err).Msg("unable to refresh jwks")
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &JWTAuthenticator{conf: conf, jwks: jwks}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	header := r.Header.Get("Authorization")
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || raw == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	var opts []jwt.ParserOption
+	if a.conf.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.conf.Issuer))
+	}
+	if a.conf.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.conf.Audience))
+	}
+
+	token, err := jwt.Parse(raw, a.jwks.Keyfunc, opts...)
+	if err != nil || !token.Valid {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return Principal{UID: sub, Claims: claims}, nil
+}
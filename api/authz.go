@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/casbin/casbin/v2"
+)
+
+// CasbinAuthorizer backs Authorizer with a Casbin enforcer, following the
+// same RBAC-via-Casbin approach as the external WeOS SecurityConfiguration.
+// A principal satisfies a route if the enforcer grants access for any one
+// of the route's required roles.
+type CasbinAuthorizer struct {
+	enforcer *casbin.Enforcer
+}
+
+// NewCasbinAuthorizer wraps an already-configured enforcer (model + policy
+// loading is left to the caller, since that varies per deployment).
+func NewCasbinAuthorizer(enforcer *casbin.Enforcer) *CasbinAuthorizer {
+	return &CasbinAuthorizer{enforcer: enforcer}
+}
+
+func (a *CasbinAuthorizer) Authorize(p Principal, roles ...string) (bool, error) {
+	for _, role := range roles {
+		ok, err := a.enforcer.Enforce(p.UID, role, "access")
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// CORSConfig configures cross-origin behavior for both the standalone HTTP
+// router (NewRouter) and the Lambda adapter, so a single allow-list applies
+// in either mode.
+type CORSConfig struct {
+	// AllowedOrigins entries may be an exact origin, "*", a glob
+	// ("https://*.example.com"), or a full regexp.
+	AllowedOrigins   []string
+	AllowedHeaders   []string
+	AllowedMethods   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+var (
+	defaultAllowedHeaders = []string{"X-Requested-With", "Content-Type", "Authorization"}
+	defaultAllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "OPTIONS", "DELETE"}
+)
+
+// NewCORSConfigFromEnv builds a CORSConfig from CORS_* environment
+// variables, falling back to the previous hard-coded wildcard/standard
+// header and method defaults when unset, so dev/staging/prod can differ
+// without recompiling.
+func NewCORSConfigFromEnv() CORSConfig {
+	conf := CORSConfig{
+		AllowedOrigins: splitEnv("CORS_ALLOWED_ORIGINS", []string{"*"}),
+		AllowedHeaders: splitEnv("CORS_ALLOWED_HEADERS", defaultAllowedHeaders),
+		AllowedMethods: splitEnv("CORS_ALLOWED_METHODS", defaultAllowedMethods),
+		ExposedHeaders: splitEnv("CORS_EXPOSED_HEADERS", nil),
+	}
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		conf.AllowCredentials, _ = strconv.ParseBool(v)
+	}
+	if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			conf.MaxAge = n
+		}
+	}
+	return conf
+}
+
+func splitEnv(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	parts := strings.Split(v, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// originMatcher precompiles the allow-list once and returns a function that
+// reports whether origin is allowed.
+func (c CORSConfig) originMatcher() func(origin string) bool {
+	allowAll := false
+	matchers := make([]*regexp.Regexp, 0, len(c.AllowedOrigins))
+	for _, pattern := range c.AllowedOrigins {
+		if pattern == "*" {
+			allowAll = true
+			continue
+		}
+		matchers = append(matchers, originPattern(pattern))
+	}
+	return func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		for _, re := range matchers {
+			if re.MatchString(origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// originPattern compiles an allow-list entry as a regexp, treating it as a
+// glob (only "*" is special) unless it already looks like a regexp.
+func originPattern(pattern string) *regexp.Regexp {
+	if strings.ContainsAny(pattern, "^$()[]|") {
+		if re, err := regexp.Compile(pattern); err == nil {
+			return re
+		}
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		if r == '*' {
+			b.WriteString(".*")
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// middleware builds the gorilla/handlers CORS middleware for this config.
+func (c CORSConfig) middleware() mux.MiddlewareFunc {
+	opts := []handlers.CORSOption{
+		handlers.AllowedHeaders(orDefault(c.AllowedHeaders, defaultAllowedHeaders)),
+		handlers.AllowedMethods(orDefault(c.AllowedMethods, defaultAllowedMethods)),
+		handlers.AllowedOriginValidator(c.originMatcher()),
+	}
+	if c.AllowCredentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	if c.MaxAge > 0 {
+		opts = append(opts, handlers.MaxAge(c.MaxAge))
+	}
+	if len(c.ExposedHeaders) > 0 {
+		opts = append(opts, handlers.ExposedHeaders(c.ExposedHeaders))
+	}
+	return handlers.CORS(opts...)
+}
+
+func orDefault(v, def []string) []string {
+	if len(v) == 0 {
+		return def
+	}
+	return v
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"context"
 
-	"github.com/holmes89/go-common/query"
 	"github.com/rs/zerolog/log"
 )
 
@@ -13,7 +12,7 @@ func NewCrudLogger[T any]() *CRUDLogger[T] {
 
 type CRUDLogger[T any] struct{}
 
-func (l *CRUDLogger[T]) Create(ctx context.Context, t T, opts query.Opts) (T, error) {
+func (l *CRUDLogger[T]) Create(ctx context.Context, t T) (T, error) {
 	var ty T
 	log.Info().Interface("body", t).Type("type", ty).Msg("create called")
 	return t, nil
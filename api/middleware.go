@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/holmes89/go-common/logging"
+	"github.com/rs/zerolog"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// CtxWithRequestID returns a context carrying the request id, retrievable
+// via RequestIDFromCtx.
+func CtxWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromCtx returns the request id stored in context, or "" if none
+// was set.
+func RequestIDFromCtx(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// LoggerFromCtx returns the request-scoped logger attached by
+// LoggingMiddleware, or the global logger if none was attached.
+func LoggerFromCtx(ctx context.Context) zerolog.Logger {
+	return logging.FromCtx(ctx)
+}
+
+// RequestIDMiddleware reads X-Request-Id off the inbound request, or
+// generates one, and echoes it back on the response so callers can
+// correlate logs across a request even when they didn't supply one.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(CtxWithRequestID(r.Context(), id)))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// LoggingMiddleware attaches a zerolog.Logger carrying the request id,
+// method, path, and remote IP to the request context (see LoggerFromCtx),
+// then logs the completed request with its status code, bytes written, and
+// latency. Run it after RequestIDMiddleware so the request id is present.
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		logger := logging.FromCtx(r.Context()).With().
+			Str("request_id", RequestIDFromCtx(r.Context())).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Str("remote_ip", r.RemoteAddr).
+			Logger()
+		ctx := logging.CtxWithLogger(r.Context(), logger)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		// Re-read from ctx rather than using logger directly: downstream
+		// middleware (e.g. withAuth) may have enriched it via
+		// logging.AddFields once the principal was resolved.
+		final := logging.FromCtx(ctx)
+		final.Info().
+			Int("status", sw.status).
+			Int("bytes", sw.bytes).
+			Dur("latency", time.Since(start)).
+			Msg("request completed")
+	})
+}
+
+// statusWriter wraps http.ResponseWriter to capture the status code and
+// byte count written, neither of which the standard interface exposes.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
@@ -7,9 +7,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"strconv"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/holmes89/go-common/query"
 	"github.com/rs/zerolog/log"
@@ -19,53 +20,61 @@ type RESTApi struct {
 }
 
 type Handler interface {
-	Mount() (string, string, http.HandlerFunc)
+	Mount() (string, []string, []string, http.HandlerFunc)
 }
 
 type Handle[T any] struct {
 	name        string
 	Path        string
 	RequestType string
-	Handle      http.HandlerFunc
+	// AltMethods lists additional HTTP methods, beyond RequestType, that
+	// should route to the same handler (e.g. PATCH alongside PUT).
+	AltMethods []string
+	Roles      []string
+	Handle     http.HandlerFunc
 }
 
 func (n *Handle[T]) Name() string {
 	return n.name
 }
 
-func NewHandler[T any](name string, path string, requestType string, fun http.HandlerFunc) *Handle[T] {
+func NewHandler[T any](name string, path string, requestType string, fun http.HandlerFunc, roles ...string) *Handle[T] {
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: requestType,
+		Roles:       roles,
 		Handle:      fun,
 	}
 }
 
-func NewGetHandler[T any](name string, path string, fun http.HandlerFunc) *Handle[T] {
+func NewGetHandler[T any](name string, path string, fun http.HandlerFunc, roles ...string) *Handle[T] {
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: "GET",
+		Roles:       roles,
 		Handle:      fun,
 	}
 }
 
-func NewPostHandler[T any](name string, path string, fun http.HandlerFunc) *Handle[T] {
+func NewPostHandler[T any](name string, path string, fun http.HandlerFunc, roles ...string) *Handle[T] {
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: "POST",
+		Roles:       roles,
 		Handle:      fun,
 	}
 }
 
-func NewFindByIDHandler[T any](name string, path string, repo Repository[T]) *Handle[T] {
+func NewFindByIDHandler[T any](name string, path string, repo Repository[T], roles ...string) *Handle[T] {
 	path = filepath.Join(path, "{id}")
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: "GET",
+		Roles:       roles,
 		Handle: func(w http.ResponseWriter, r *http.Request) {
 			vars := mux.Vars(r)
 			ctx := r.Context()
@@ -82,29 +91,128 @@ func NewFindByIDHandler[T any](name string, path string, repo Repository[T]) *Ha
 	}
 }
 
-func NewFindAllHandler[T any](name string, path string, repo Repository[T]) *Handle[T] {
+// ListMeta carries pagination metadata for a list response. Count reflects
+// the number of items in this page, not the table-wide total; DynamoDB has
+// no cheap way to report that, so clients should use NextCursor to know
+// whether more pages remain.
+type ListMeta struct {
+	Count      int    `json:"count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListLinks holds the JSON:API-style navigation links for a list response.
+type ListLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// ListResponse envelopes a paginated collection so clients can iterate
+// deterministically instead of relying on a raw array.
+type ListResponse[T any] struct {
+	Data  []T       `json:"data"`
+	Meta  ListMeta  `json:"meta"`
+	Links ListLinks `json:"links"`
+}
+
+// parseListQuery reads offset/limit/sort/filter from the query string,
+// falling back to the JSON:API-style page[size]/page[after] pair when the
+// plain params aren't set.
+func parseListQuery(ctx context.Context, q url.Values) (query.Opts, error) {
+	offset := q.Get("offset")
+	if offset == "" {
+		offset = q.Get("page[after]")
+	}
+
+	limit := int32(0)
+	limitParam := q.Get("limit")
+	if limitParam == "" {
+		limitParam = q.Get("page[size]")
+	}
+	if limitParam != "" {
+		l, err := strconv.ParseInt(limitParam, 10, 32)
+		if err != nil {
+			return query.Opts{}, fmt.Errorf("invalid limit: %s", limitParam)
+		}
+		limit = int32(l)
+	}
+
+	return query.ParseOpts(ctx, offset, limit, q["sort"], q["filter"])
+}
+
+func NewFindAllHandler[T any](name string, path string, repo Repository[T], roles ...string) *Handle[T] {
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: "GET",
+		Roles:       roles,
 		Handle: func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
-			// query.ParseOpts()
-			resource, err := repo.FindAll(ctx, query.Opts{}) // todo redo parser
+			opts, err := parseListQuery(ctx, r.URL.Query())
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			result, err := repo.FindAll(ctx, opts)
 			if err != nil {
 				http.Error(w, "unable to find resource", http.StatusInternalServerError)
 				return
 			}
-			EncodeJSONResponse(r.Context(), w, resource)
+
+			EncodeJSONResponse(ctx, w, newListResponse(r, result))
 		},
 	}
 }
 
-func NewCreateHandler[T any](name string, path string, factory Factory[T]) *Handle[T] {
+// newListResponse builds the data/meta/links envelope for a page of
+// results, carrying the current cursor params forward into the next link.
+// Links.Prev is left empty: cursor pagination has no cheap way to derive
+// the previous page's cursor, so there's nothing honest to put there.
+func newListResponse[T any](r *http.Request, result query.Result[T]) ListResponse[T] {
+	links := ListLinks{Self: r.URL.RequestURI()}
+
+	q := r.URL.Query()
+	offsetKey := "offset"
+	if q.Get("offset") == "" && q.Get("page[after]") != "" {
+		offsetKey = "page[after]"
+	}
+
+	if result.NextCursor != "" {
+		next := url.Values{}
+		for k, v := range q {
+			next[k] = v
+		}
+		next.Set(offsetKey, result.NextCursor)
+		nextURL := *r.URL
+		nextURL.RawQuery = next.Encode()
+		links.Next = nextURL.RequestURI()
+	}
+
+	return ListResponse[T]{
+		Data: result.Items,
+		Meta: ListMeta{
+			Count:      len(result.Items),
+			NextCursor: result.NextCursor,
+		},
+		Links: links,
+	}
+}
+
+// writeError writes a structured JSON error body for request failures that
+// happen before a handler can produce a resource response.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func NewCreateHandler[T any](name string, path string, factory Factory[T], roles ...string) *Handle[T] {
 	return &Handle[T]{
 		name:        name,
 		Path:        path,
 		RequestType: "POST",
+		Roles:       roles,
 		Handle: func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 			resource, err := extractBody[T](r)
@@ -123,6 +231,56 @@ func NewCreateHandler[T any](name string, path string, factory Factory[T]) *Hand
 	}
 }
 
+func NewUpdateHandler[T any](name string, path string, factory Factory[T], roles ...string) *Handle[T] {
+	path = filepath.Join(path, "{id}")
+	return &Handle[T]{
+		name:        name,
+		Path:        path,
+		RequestType: "PUT",
+		AltMethods:  []string{"PATCH"},
+		Roles:       roles,
+		Handle: func(w http.ResponseWriter, r *http.Request) {
+			vars := mux.Vars(r)
+			ctx := r.Context()
+			id := vars["id"]
+
+			resource, err := extractBody[T](r)
+			if err != nil {
+				http.Error(w, "invalid resource", http.StatusBadRequest)
+				return
+			}
+
+			resource, err = factory.Update(ctx, id, resource)
+			if err != nil {
+				http.Error(w, "unable to update resource", http.StatusInternalServerError)
+				return
+			}
+			EncodeJSONResponse(ctx, w, resource)
+		},
+	}
+}
+
+func NewDeleteHandler[T any](name string, path string, removal Removal[T], roles ...string) *Handle[T] {
+	path = filepath.Join(path, "{id}")
+	return &Handle[T]{
+		name:        name,
+		Path:        path,
+		RequestType: "DELETE",
+		Roles:       roles,
+		Handle: func(w http.ResponseWriter, r *http.Request) {
+			vars := mux.Vars(r)
+			ctx := r.Context()
+			id := vars["id"]
+
+			if err := removal.Delete(ctx, id); err != nil {
+				http.Error(w, "unable to delete resource", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		},
+	}
+}
+
 func extractBody[T any](r *http.Request) (resource T, err error) {
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -138,8 +296,9 @@ func extractBody[T any](r *http.Request) (resource T, err error) {
 	return resource, nil
 }
 
-func (h *Handle[T]) Mount() (string, string, http.HandlerFunc) {
-	return h.Path, h.RequestType, h.Handle
+func (h *Handle[T]) Mount() (string, []string, []string, http.HandlerFunc) {
+	methods := append([]string{h.RequestType}, h.AltMethods...)
+	return h.Path, methods, h.Roles, h.Handle
 }
 
 type Control struct {
@@ -149,22 +308,25 @@ type Control struct {
 }
 
 type Controller interface {
-	Mount(*mux.Router)
+	Mount(mr *mux.Router, authn Authenticator, authz Authorizer)
 }
 
 func (n *Control) Name() string {
 	return n.name
 }
 
-func (n *Control) Mount(mr *mux.Router) {
+// Mount registers each handler under the controller's root path. authn and
+// authz may be nil; routes with no required roles run unauthenticated in
+// that case, and any route that does declare roles is rejected.
+func (n *Control) Mount(mr *mux.Router, authn Authenticator, authz Authorizer) {
 	log.Info().Str("path", n.RootPath).Msg("creating controller...")
 	r := mr.PathPrefix(fmt.Sprintf("/%s", n.RootPath)).Subrouter()
 
 	for _, handler := range n.Handlers {
-		path, t, fun := handler.Mount()
+		path, methods, roles, fun := handler.Mount()
 		log.Info().Str("path", path).Str("root", n.RootPath).Msg("mounting path")
-		r.HandleFunc(fmt.Sprintf("/%s", path), fun).
-			Methods(t, "OPTIONS")
+		r.HandleFunc(fmt.Sprintf("/%s", path), withAuth(authn, authz, roles, fun)).
+			Methods(append(methods, "OPTIONS")...)
 	}
 }
 
@@ -173,6 +335,8 @@ func NewCRUDController[T any](name string, path string, svc CRUD[T]) Controller
 		NewCreateHandler[T](fmt.Sprintf("createHandler%s", name), "", svc),
 		NewFindByIDHandler[T](fmt.Sprintf("findbyIDHandler%s", name), "", svc),
 		NewFindAllHandler[T](fmt.Sprintf("findAllHandler%s", name), "", svc),
+		NewUpdateHandler[T](fmt.Sprintf("updateHandler%s", name), "", svc),
+		NewDeleteHandler[T](fmt.Sprintf("deleteHandler%s", name), "", svc),
 	})
 }
 
@@ -190,19 +354,17 @@ func NewController(root string, handlers []Handler) Controller {
 	}
 }
 
-func NewRouter(controllers []Controller) *mux.Router {
+// NewRouter mounts each controller onto a fresh mux.Router. authn and authz
+// are optional: pass nil for either when no route requires authentication or
+// authorization.
+func NewRouter(controllers []Controller, authn Authenticator, authz Authorizer, cors CORSConfig) *mux.Router {
 	//todo check for no router, create default
 	log.Info().Int("count", len(controllers)).Msg("creating controllers...")
 	mux := mux.NewRouter()
 	for _, c := range controllers {
-		c.Mount(mux)
+		c.Mount(mux, authn, authz)
 	}
-	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
-	originsOk := handlers.AllowedOrigins([]string{"*"}) // TODO env
-	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "OPTIONS", "DELETE"})
-
-	cors := handlers.CORS(originsOk, headersOk, methodsOk)
-	mux.Use(cors)
+	mux.Use(RequestIDMiddleware, LoggingMiddleware, cors.middleware())
 	return mux
 }
 
@@ -218,7 +380,7 @@ type Factory[T any] interface {
 }
 
 type Repository[T any] interface {
-	FindAll(context.Context, query.Opts) ([]T, error)
+	FindAll(context.Context, query.Opts) (query.Result[T], error)
 	FindByID(context.Context, string) (T, error)
 }
 
@@ -3,9 +3,11 @@ package api
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/core"
@@ -64,9 +66,11 @@ func NewServer(debug ...bool) *Server {
 	}
 }
 
+const defaultDrainTimeout = 15 * time.Second
+
 func (s *Server) Run() {
 	handler := fx.Annotate(NewAPIGatewayHandler)
-	s.providers = append(s.providers, handler)
+	s.providers = append(s.providers, handler, NewCORSConfigFromEnv)
 	fx.New(
 		fx.Provide(
 			s.providers...,
@@ -75,19 +79,81 @@ func (s *Server) Run() {
 	).Run()
 }
 
+// RunHTTP starts a standalone http.Server on addr using the same
+// *mux.Router that the Lambda adapter would otherwise wrap, so the same
+// Controller set runs unchanged in either mode. It registers fx lifecycle
+// hooks so the server starts on OnStart and, on SIGINT/SIGTERM, drains
+// in-flight requests for up to drainTimeout on OnStop before exiting.
+func (s *Server) RunHTTP(addr string, drainTimeout ...time.Duration) {
+	timeout := defaultDrainTimeout
+	if len(drainTimeout) > 0 {
+		timeout = drainTimeout[0]
+	}
+
+	providers := append(append([]any{}, s.providers...), NewCORSConfigFromEnv)
+	fx.New(
+		fx.Provide(
+			providers...,
+		),
+		fx.Invoke(func(lc fx.Lifecycle, router *mux.Router) {
+			registerHealthRoutes(router)
+			srv := &http.Server{
+				Addr:    addr,
+				Handler: router,
+			}
+
+			lc.Append(fx.Hook{
+				OnStart: func(ctx context.Context) error {
+					ln, err := net.Listen("tcp", addr)
+					if err != nil {
+						return err
+					}
+					log.Info().Str("addr", addr).Msg("starting http server...")
+					go func() {
+						if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+							log.Error().Err(err).Msg("http server stopped unexpectedly")
+						}
+					}()
+					return nil
+				},
+				OnStop: func(ctx context.Context) error {
+					drainCtx, cancel := context.WithTimeout(ctx, timeout)
+					defer cancel()
+					log.Info().Dur("timeout", timeout).Msg("draining http server...")
+					return srv.Shutdown(drainCtx)
+				},
+			})
+		}),
+		fx.StopTimeout(timeout+time.Second),
+	).Run()
+}
+
+// registerHealthRoutes mounts liveness/readiness endpoints used by
+// container orchestrators and load balancers in standalone HTTP mode.
+func registerHealthRoutes(r *mux.Router) {
+	r.HandleFunc("/livez", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods(http.MethodGet)
+}
+
 type LambdaHandler interface {
 	Handle(context.Context, core.SwitchableAPIGatewayRequest) (*core.SwitchableAPIGatewayResponse, error)
 }
 
 type APIGatewayHandler struct {
-	adapter *gorillamux.GorillaMuxAdapter
+	adapter     *gorillamux.GorillaMuxAdapter
+	allowOrigin func(origin string) bool
 }
 
-func NewAPIGatewayHandler(router *mux.Router) LambdaHandler {
+func NewAPIGatewayHandler(router *mux.Router, cors CORSConfig) LambdaHandler {
 
 	adapter := gorillamux.New(router)
 	return &APIGatewayHandler{
-		adapter: adapter,
+		adapter:     adapter,
+		allowOrigin: cors.originMatcher(),
 	}
 }
 
@@ -95,6 +161,8 @@ type contextKeys int
 
 const (
 	userUIDKey contextKeys = iota
+	claimsKey
+	requestIDKey
 )
 
 // CtxWithUserUID will return a context with UID stored as value.
@@ -102,6 +170,19 @@ func CtxWithUserUID(ctx context.Context, uid interface{}) context.Context {
 	return context.WithValue(ctx, userUIDKey, uid)
 }
 
+// CtxWithClaims will return a context with the resolved principal's claims
+// stored as a value.
+func CtxWithClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromCtx will return the claims stored in context, or nil if none
+// were set.
+func ClaimsFromCtx(ctx context.Context) map[string]interface{} {
+	claims, _ := ctx.Value(claimsKey).(map[string]interface{})
+	return claims
+}
+
 // UserUIDFromCtx will return user uid stored in context.
 func UserUIDFromCtx(ctx context.Context) string {
 	s, ok := ctx.Value(userUIDKey).(string)
@@ -122,8 +203,9 @@ func (s *APIGatewayHandler) Handle(ctx context.Context, request core.SwitchableA
 	uctx := ctx
 	if cmap, ok := request.Version1().RequestContext.Authorizer["claims"]; ok {
 		if claims, ok := cmap.(map[string]interface{}); ok {
+			uctx = CtxWithClaims(uctx, claims)
 			if sub, ok := claims["sub"]; ok {
-				uctx = CtxWithUserUID(ctx, sub)
+				uctx = CtxWithUserUID(uctx, sub)
 			} else {
 				log.Warn().Msg("no subject on token")
 			}
@@ -143,8 +225,11 @@ func (s *APIGatewayHandler) Handle(ctx context.Context, request core.SwitchableA
 	resp, err := s.adapter.ProxyWithContext(uctx, request)
 	origin, ok := request.Version1().Headers["Origin"]
 	if ok {
-		// TODO check origin
-		resp.Version1().Headers["Access-Control-Allow-Origin"] = origin
+		if s.allowOrigin(origin) {
+			resp.Version1().Headers["Access-Control-Allow-Origin"] = origin
+		} else {
+			log.Warn().Str("origin", origin).Msg("rejected origin")
+		}
 	} else {
 		log.Warn().Msg("missing origin")
 	}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/schema"
+	"github.com/holmes89/go-common/dynamo"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	validate      = validator.New()
+	schemaDecoder = newSchemaDecoder()
+)
+
+func newSchemaDecoder() *schema.Decoder {
+	d := schema.NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	d.SetAliasTag("json")
+	return d
+}
+
+// Validatable lets a request type layer business-rule validation on top of
+// the struct-tag validation every typed handler already runs.
+type Validatable interface {
+	Validate() error
+}
+
+// Error is a structured, HTTP-status-carrying error that typed handlers
+// return directly so callers don't have to duplicate status/body wiring.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	Status  int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NotFound builds a 404 Error for the named resource.
+func NotFound(resource string) *Error {
+	return &Error{Code: "not_found", Message: fmt.Sprintf("%s not found", resource), Status: http.StatusNotFound}
+}
+
+// Invalid builds a 422 Error, e.g. for request validation failures.
+func Invalid(message string, details any) *Error {
+	return &Error{Code: "invalid", Message: message, Details: details, Status: http.StatusUnprocessableEntity}
+}
+
+// Internal builds a 500 Error for anything that isn't a recognized sentinel.
+func Internal(message string) *Error {
+	return &Error{Code: "internal", Message: message, Status: http.StatusInternalServerError}
+}
+
+// mapError normalizes any error returned by a typed handler's fn (or by
+// request decoding/validation) into an Error with an HTTP status attached.
+func mapError(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	if errors.Is(err, dynamo.ErrNotFound) {
+		return NotFound("resource")
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		return Invalid("validation failed", verrs.Error())
+	}
+
+	log.Error().Err(err).Msg("unhandled handler error")
+	return Internal("unable to process request")
+}
+
+// writeTypedError encodes an Error as the JSON response body at its status.
+func writeTypedError(w http.ResponseWriter, err *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(err)
+}
+
+// decodeRequest builds a Req from the request body (if present) and query
+// string, using the same `json` struct tags for both, then runs
+// validator.v10 struct-tag validation followed by Req's own Validate method
+// if it implements Validatable.
+func decodeRequest[Req any](r *http.Request) (Req, error) {
+	var req Req
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			return req, Invalid("invalid request body", err.Error())
+		}
+	}
+
+	if err := r.ParseForm(); err == nil && len(r.Form) > 0 {
+		if err := schemaDecoder.Decode(&req, r.Form); err != nil {
+			return req, Invalid("invalid request parameters", err.Error())
+		}
+	}
+
+	if err := validate.Struct(req); err != nil {
+		return req, err
+	}
+
+	if v, ok := any(req).(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			return req, err
+		}
+	}
+
+	return req, nil
+}
+
+// NewTypedHandler decodes Req from the request (body, form, and query all
+// feed the same struct tags), validates it, invokes fn, and encodes the
+// result. Errors from decoding, validation, or fn are normalized via
+// mapError before being written.
+func NewTypedHandler[Req, Resp any](name, method, path string, fn func(context.Context, Req) (Resp, error), roles ...string) *Handle[Resp] {
+	return &Handle[Resp]{
+		name:        name,
+		Path:        path,
+		RequestType: method,
+		Roles:       roles,
+		Handle: func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			req, err := decodeRequest[Req](r)
+			if err != nil {
+				writeTypedError(w, mapError(err))
+				return
+			}
+
+			resp, err := fn(ctx, req)
+			if err != nil {
+				writeTypedError(w, mapError(err))
+				return
+			}
+
+			EncodeJSONResponse(ctx, w, resp)
+		},
+	}
+}
@@ -2,18 +2,117 @@ package dynamo
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/holmes89/go-common/logging"
 	"github.com/holmes89/go-common/query"
+	"github.com/rs/zerolog/log"
 )
 
+const defaultLimit = 10
+
+// encodeCursor turns a DynamoDB LastEvaluatedKey into an opaque string
+// token that callers can round-trip back as ExclusiveStartKey. Keys on
+// these tables are always string-valued (see Serializable.PK/SK), so
+// non-string attributes are dropped rather than supported.
+func encodeCursor(ctx context.Context, key map[string]types.AttributeValue) string {
+	if len(key) == 0 {
+		return ""
+	}
+	raw := make(map[string]string, len(key))
+	for k, v := range key {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			raw[k] = s.Value
+		}
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		logger := logging.FromCtx(ctx)
+		logger.Error().Err(err).Msg("unable to encode cursor")
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reverses encodeCursor, returning nil for an empty cursor so
+// callers can pass it straight through as ExclusiveStartKey.
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	raw := make(map[string]string)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	key := make(map[string]types.AttributeValue, len(raw))
+	for k, v := range raw {
+		key[k] = &types.AttributeValueMemberS{Value: v}
+	}
+	return key, nil
+}
+
+// scanIndexForward resolves opts.Sort to the single ScanIndexForward a
+// Dynamo Query supports: it can only order by the range key it already
+// has, so more than one sort field is rejected rather than silently
+// honoring just one of them.
+func scanIndexForward(sort query.Sort) (bool, error) {
+	if len(sort) == 0 {
+		return false, nil
+	}
+	if len(sort) > 1 {
+		return false, fmt.Errorf("sort supports a single field, got %d", len(sort))
+	}
+	for field, dir := range sort {
+		switch strings.ToLower(dir) {
+		case "asc":
+			return true, nil
+		case "desc", "":
+			return false, nil
+		default:
+			return false, fmt.Errorf("invalid sort direction for %q: %s", field, dir)
+		}
+	}
+	return false, nil
+}
+
+// filterExpression builds an equality-only FilterExpression ANDing each
+// field:value pair in filter, with names/values namespaced (f0, f1, ...)
+// so they don't collide with the partition-key condition. Dynamo applies
+// FilterExpression after reading up to Limit items, so a filtered page may
+// come back with fewer than Limit items even when more match overall.
+func filterExpression(filter query.Filter) (expr *string, names map[string]string, values map[string]types.AttributeValue) {
+	if len(filter) == 0 {
+		return nil, nil, nil
+	}
+	names = make(map[string]string, len(filter))
+	values = make(map[string]types.AttributeValue, len(filter))
+	conds := make([]string, 0, len(filter))
+	i := 0
+	for field, value := range filter {
+		nameKey := fmt.Sprintf("#f%d", i)
+		valueKey := fmt.Sprintf(":f%d", i)
+		names[nameKey] = field
+		values[valueKey] = &types.AttributeValueMemberS{Value: fmt.Sprintf("%v", value)}
+		conds = append(conds, fmt.Sprintf("%s = %s", nameKey, valueKey))
+		i++
+	}
+	e := strings.Join(conds, " AND ")
+	return &e, names, values
+}
+
 var (
 	notfound    *types.ResourceNotFoundException
 	ErrNotFound = errors.New("entity not found")
@@ -41,7 +140,7 @@ func New[T Serializable[T]](conf DBConf) *Conn[T] {
 	// Load the Shared AWS Configuration (~/.aws/config)
 	cfg, err := loadConfig()
 	if err != nil {
-		log.Println("unable to load config", err)
+		log.Error().Err(err).Msg("unable to load config")
 	}
 	// Create DynamoDB client
 	svc := dynamodb.NewFromConfig(cfg)
@@ -54,7 +153,7 @@ func New[T Serializable[T]](conf DBConf) *Conn[T] {
 
 func loadConfig() (aws.Config, error) {
 	if conn := os.Getenv("DYNAMODB_ENDPOINT"); conn != "" {
-		log.Println("using local database connection")
+		log.Info().Msg("using local database connection")
 		return config.LoadDefaultConfig(context.TODO(),
 			config.WithRegion(("us-east-1")),
 			config.WithEndpointResolverWithOptions(aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
@@ -65,6 +164,7 @@ func loadConfig() (aws.Config, error) {
 }
 
 func (conn *Conn[T]) FindByID(ctx context.Context, id string) (T, error) {
+	logger := logging.FromCtx(ctx)
 	var t T
 	params := &dynamodb.GetItemInput{
 		TableName: aws.String(conn.conf.TableName),
@@ -78,16 +178,16 @@ func (conn *Conn[T]) FindByID(ctx context.Context, id string) (T, error) {
 	resp, err := conn.db.GetItem(ctx, params)
 	if err != nil {
 		if errors.As(err, &notfound) {
-			log.Println("no resources found")
+			logger.Info().Msg("no resources found")
 			return rs, nil
 		}
-		log.Println("unable to find ", err)
+		logger.Error().Err(err).Msg("unable to find")
 		return rs, errors.New("unable to fetch ")
 	}
 
 	rs, err = t.Deserialize(resp.Item)
 	if err != nil {
-		log.Println("unable to unmarshal ", err)
+		logger.Error().Err(err).Msg("unable to unmarshal")
 		return rs, errors.New("failed to scan ")
 	}
 
@@ -95,6 +195,7 @@ func (conn *Conn[T]) FindByID(ctx context.Context, id string) (T, error) {
 }
 
 func (conn *Conn[T]) FindByPkAndSk(ctx context.Context, pk string, sk string) (T, error) {
+	logger := logging.FromCtx(ctx)
 	var t T
 	params := &dynamodb.GetItemInput{
 		TableName: aws.String(conn.conf.TableName),
@@ -108,104 +209,232 @@ func (conn *Conn[T]) FindByPkAndSk(ctx context.Context, pk string, sk string) (T
 	resp, err := conn.db.GetItem(ctx, params)
 	if err != nil {
 		if errors.As(err, &notfound) {
-			log.Println("no resources found")
+			logger.Info().Msg("no resources found")
 			return rs, nil
 		}
-		log.Println("unable to find ", err)
+		logger.Error().Err(err).Msg("unable to find")
 		return rs, errors.New("unable to fetch ")
 	}
 
 	rs, err = t.Deserialize(resp.Item)
 	if err != nil {
-		log.Println("unable to unmarshal ", err)
+		logger.Error().Err(err).Msg("unable to unmarshal")
 		return rs, errors.New("failed to scan ")
 	}
 
 	return rs, nil
 }
 
-func (conn *Conn[T]) FindAll(ctx context.Context, filter query.Opts) ([]T, error) {
+func (conn *Conn[T]) FindAll(ctx context.Context, opts query.Opts) (query.Result[T], error) {
+	logger := logging.FromCtx(ctx)
 	var t T
+
+	startKey, err := decodeCursor(opts.Pagination.Offset)
+	if err != nil {
+		return query.Result[T]{}, err
+	}
+
+	forward, err := scanIndexForward(opts.Sort)
+	if err != nil {
+		return query.Result[T]{}, err
+	}
+
+	limit := int32(defaultLimit)
+	if opts.Pagination.Limit > 0 {
+		limit = int32(opts.Pagination.Limit)
+	}
+
 	params := &dynamodb.QueryInput{
 		TableName:              aws.String(conn.conf.TableName),
-		Limit:                  aws.Int32(10),
+		Limit:                  aws.Int32(limit),
 		KeyConditionExpression: aws.String("PK = :key"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":key": &types.AttributeValueMemberS{Value: t.PK()},
 		},
-		ScanIndexForward: aws.Bool(false),
+		ScanIndexForward:  aws.Bool(forward),
+		ExclusiveStartKey: startKey,
+	}
+	if filterExpr, filterNames, filterValues := filterExpression(opts.Filter); filterExpr != nil {
+		params.FilterExpression = filterExpr
+		params.ExpressionAttributeNames = filterNames
+		for k, v := range filterValues {
+			params.ExpressionAttributeValues[k] = v
+		}
 	}
 
 	entities := make([]T, 0)
 	resp, err := conn.db.Query(ctx, params)
 	if err != nil {
 		if errors.As(err, &notfound) {
-			log.Println("no resources found")
-			return entities, nil
+			logger.Info().Msg("no resources found")
+			return query.Result[T]{Items: entities}, nil
 		}
-		log.Println("unable to fetch ", err)
-		return entities, errors.New("unable to fetch all ")
+		logger.Error().Err(err).Msg("unable to fetch")
+		return query.Result[T]{}, errors.New("unable to fetch all ")
 	}
 
 	entities, err = t.DeserializeList(resp.Items)
 
 	if err != nil {
-		log.Println("unable to unmarshal ", err)
-		return entities, errors.New("unable to fetch all ")
+		logger.Error().Err(err).Msg("unable to unmarshal")
+		return query.Result[T]{}, errors.New("unable to fetch all ")
 	}
-	return entities, nil
+	return query.Result[T]{
+		Items:      entities,
+		NextCursor: encodeCursor(ctx, resp.LastEvaluatedKey),
+	}, nil
 }
 
-func (conn *Conn[T]) FindByPk(ctx context.Context, pk string, filter query.Opts) ([]T, error) {
+func (conn *Conn[T]) FindByPk(ctx context.Context, pk string, opts query.Opts) (query.Result[T], error) {
+	logger := logging.FromCtx(ctx)
 	var t T
+	startKey, err := decodeCursor(opts.Pagination.Offset)
+	if err != nil {
+		return query.Result[T]{}, err
+	}
+
+	forward, err := scanIndexForward(opts.Sort)
+	if err != nil {
+		return query.Result[T]{}, err
+	}
+
+	limit := int32(defaultLimit)
+	if opts.Pagination.Limit > 0 {
+		limit = int32(opts.Pagination.Limit)
+	}
+
 	params := &dynamodb.QueryInput{
 		TableName:              aws.String(conn.conf.TableName),
-		Limit:                  aws.Int32(10),
+		Limit:                  aws.Int32(limit),
 		KeyConditionExpression: aws.String("PK = :key"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":key": &types.AttributeValueMemberS{Value: pk},
 		},
-		ScanIndexForward: aws.Bool(false),
+		ScanIndexForward:  aws.Bool(forward),
+		ExclusiveStartKey: startKey,
+	}
+	if filterExpr, filterNames, filterValues := filterExpression(opts.Filter); filterExpr != nil {
+		params.FilterExpression = filterExpr
+		params.ExpressionAttributeNames = filterNames
+		for k, v := range filterValues {
+			params.ExpressionAttributeValues[k] = v
+		}
 	}
 
 	entities := make([]T, 0)
 	resp, err := conn.db.Query(ctx, params)
 	if err != nil {
 		if errors.As(err, &notfound) {
-			log.Println("no resources found")
-			return entities, nil
+			logger.Info().Msg("no resources found")
+			return query.Result[T]{Items: entities}, nil
 		}
-		log.Println("unable to fetch ", err)
-		return entities, errors.New("unable to fetch all ")
+		logger.Error().Err(err).Msg("unable to fetch")
+		return query.Result[T]{}, errors.New("unable to fetch all ")
 	}
 
 	entities, err = t.DeserializeList(resp.Items)
 
 	if err != nil {
-		log.Println("unable to unmarshal ", err)
-		return entities, errors.New("unable to fetch all ")
+		logger.Error().Err(err).Msg("unable to unmarshal")
+		return query.Result[T]{}, errors.New("unable to fetch all ")
 	}
-	return entities, nil
+	return query.Result[T]{
+		Items:      entities,
+		NextCursor: encodeCursor(ctx, resp.LastEvaluatedKey),
+	}, nil
 }
 
 func (conn *Conn[T]) Create(ctx context.Context, r T) (T, error) {
+	logger := logging.FromCtx(ctx)
 
 	rs, err := r.Serialize()
 	if err != nil {
-		log.Println("unable to marshal  message", err)
+		logger.Error().Err(err).Msg("unable to marshal message")
 		return r, errors.New("failed to insert ")
 	}
 
-	fmt.Printf("table:%s\npk:%s\nsk:%s", conn.conf.TableName, r.PK(), r.SK(nil))
-
 	params := &dynamodb.PutItemInput{
 		Item:      rs,
 		TableName: aws.String(conn.conf.TableName),
 	}
 
 	if _, err := conn.db.PutItem(ctx, params); err != nil {
-		log.Println("unable to put message", err)
+		logger.Error().Err(err).Msg("unable to put message")
 		return r, errors.New("failed to insert ")
 	}
 	return r, nil
 }
+
+var conditionalCheckFailed *types.ConditionalCheckFailedException
+
+func (conn *Conn[T]) Update(ctx context.Context, id string, r T) (T, error) {
+	logger := logging.FromCtx(ctx)
+	item, err := r.Serialize()
+	if err != nil {
+		logger.Error().Err(err).Msg("unable to marshal message")
+		return r, errors.New("failed to update ")
+	}
+
+	key := map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: r.PK()},
+		"SK": &types.AttributeValueMemberS{Value: r.SK(&id)},
+	}
+
+	names := make(map[string]string, len(item))
+	values := make(map[string]types.AttributeValue, len(item))
+	sets := make([]string, 0, len(item))
+	for k, v := range item {
+		if k == "PK" || k == "SK" {
+			continue
+		}
+		nameKey := "#" + k
+		valueKey := ":" + k
+		names[nameKey] = k
+		values[valueKey] = v
+		sets = append(sets, fmt.Sprintf("%s = %s", nameKey, valueKey))
+	}
+
+	if len(sets) == 0 {
+		return r, nil
+	}
+
+	params := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(conn.conf.TableName),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+	}
+
+	if _, err := conn.db.UpdateItem(ctx, params); err != nil {
+		if errors.As(err, &conditionalCheckFailed) {
+			return r, ErrNotFound
+		}
+		logger.Error().Err(err).Msg("unable to update")
+		return r, errors.New("failed to update ")
+	}
+	return r, nil
+}
+
+func (conn *Conn[T]) Delete(ctx context.Context, id string) error {
+	logger := logging.FromCtx(ctx)
+	var t T
+	params := &dynamodb.DeleteItemInput{
+		TableName: aws.String(conn.conf.TableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: t.PK()},
+			"SK": &types.AttributeValueMemberS{Value: t.SK(&id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_exists(SK)"),
+	}
+
+	if _, err := conn.db.DeleteItem(ctx, params); err != nil {
+		if errors.As(err, &conditionalCheckFailed) {
+			return ErrNotFound
+		}
+		logger.Error().Err(err).Msg("unable to delete")
+		return errors.New("failed to delete ")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package logging carries a request-scoped zerolog.Logger through
+// context.Context so packages that don't share an import relationship
+// (e.g. api and dynamo) can still log with the same correlated fields.
+package logging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type loggerKey struct{}
+
+// holder lets AddFields enrich the logger already attached to ctx in place,
+// so a reference to it taken earlier (e.g. by an outer middleware that
+// logs after a downstream handler returns) observes fields added later in
+// the request, without needing the enriched context threaded back up.
+type holder struct {
+	mu     sync.Mutex
+	logger zerolog.Logger
+}
+
+// CtxWithLogger returns a context carrying logger, retrievable via FromCtx.
+func CtxWithLogger(ctx context.Context, logger zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, &holder{logger: logger})
+}
+
+// FromCtx returns the logger attached to ctx, or the global zerolog logger
+// if none was attached.
+func FromCtx(ctx context.Context) zerolog.Logger {
+	if h, ok := ctx.Value(loggerKey{}).(*holder); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.logger
+	}
+	return log.Logger
+}
+
+// AddFields enriches the logger already attached to ctx by CtxWithLogger
+// with additional fields, in place. It's a no-op if ctx has no attached
+// logger. Use this instead of re-attaching via CtxWithLogger when later
+// code in the same request (e.g. an outer middleware) needs to observe the
+// fields too.
+func AddFields(ctx context.Context, fields func(zerolog.Context) zerolog.Context) {
+	h, ok := ctx.Value(loggerKey{}).(*holder)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.logger = fields(h.logger.With()).Logger()
+}
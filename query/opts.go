@@ -21,6 +21,13 @@ type Opts struct {
 	Pagination
 }
 
+// Result wraps a page of items returned by a Repository alongside the
+// cursor needed to fetch the next page.
+type Result[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
 func ParseOpts(
 	ctx context.Context,
 	offset string,